@@ -0,0 +1,114 @@
+/*
+Copyright 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controlplanemachineset
+
+import (
+	"context"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/util/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// conditionDegraded is a status condition type used to report that the ControlPlaneMachineSet cannot
+	// make progress and requires user intervention to resolve.
+	conditionDegraded = "Degraded"
+
+	// conditionRemediating is a status condition type used to report that an unhealthy control plane
+	// Machine is being, or is waiting to be, remediated.
+	conditionRemediating = "Remediating"
+
+	// conditionUpgrading is a status condition type used to report that a rollout is in progress and that
+	// MachineHealthCheck remediation is being paused for the Machines participating in it.
+	conditionUpgrading = "Upgrading"
+
+	// reasonInvalidStrategy is a condition reason used to inform the user that spec.strategy.type is
+	// unset, unrecognised, or set to a strategy that is not supported.
+	reasonInvalidStrategy = "InvalidStrategy"
+)
+
+// ControlPlaneMachineSetReconciler reconciles a ControlPlaneMachineSet object, keeping the control plane
+// Machines it owns up to date with its template and, where configured, remediating unhealthy ones.
+type ControlPlaneMachineSetReconciler struct {
+	// Client is used to fetch the ControlPlaneMachineSet being reconciled, and to list and patch the
+	// MachineHealthChecks and Machines in Namespace.
+	Client client.Client
+
+	// Namespace is the namespace in which the ControlPlaneMachineSet and its Machines live. This is
+	// always the openshift-machine-api namespace in a real cluster, but is configurable for testing.
+	Namespace string
+
+	// MachineProvider supplies the platform-specific operations, Machine discovery, creation, deletion,
+	// and in-place patching, that the update and remediation strategies drive.
+	MachineProvider machineproviders.MachineProvider
+
+	// SSACache records the last Server-Side Apply request successfully sent for each Machine, so that a
+	// reconcile pass that would otherwise re-send an identical request can skip it instead. It is
+	// initialized by SetupWithManager and is safe for concurrent use.
+	SSACache *ssa.Cache
+}
+
+// SetupWithManager sets up the controller with the Manager, and initializes the fields that are not
+// expected to be set by the caller.
+func (r *ControlPlaneMachineSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.SSACache == nil {
+		r.SSACache = ssa.NewCache()
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&machinev1.ControlPlaneMachineSet{}).
+		Owns(&machinev1beta1.Machine{}).
+		Complete(r)
+}
+
+// Reconcile implements the main reconcile loop for the ControlPlaneMachineSet controller. It first
+// remediates any unhealthy control plane Machine, then hands off to the configured update strategy to
+// bring the remaining Machines up to date with the ControlPlaneMachineSet's template.
+func (r *ControlPlaneMachineSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Namespace, "name", req.Name)
+
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	if err := r.Client.Get(ctx, req.NamespacedName, cpms); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("error fetching control plane machine set: %w", err)
+	}
+
+	if cpms.Spec.Replicas == nil {
+		return ctrl.Result{}, errReplicasRequired
+	}
+
+	machineInfos, err := r.MachineProvider.MachineInfos(ctx, logger)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error gathering machine information: %w", err)
+	}
+
+	if result, err := r.reconcileMachineRemediation(ctx, logger, cpms, r.MachineProvider, machineInfos); err != nil || result.Requeue || result.RequeueAfter > 0 {
+		return result, err
+	}
+
+	return r.reconcileMachineUpdates(ctx, logger, cpms, r.MachineProvider, machineInfos)
+}
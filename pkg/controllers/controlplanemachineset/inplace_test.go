@@ -0,0 +1,270 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controlplanemachineset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	machinev1 "github.com/openshift/api/machine/v1"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/util/ssa"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeInPlaceProvider is a minimal machineproviders.MachineProvider for use by the
+// reconcileMachineInPlaceUpdate tests below. It records which indexes it was asked to create a Machine
+// for, and which Machines it was asked to patch in place, and lets each test control whether in-place
+// changes are supported.
+type fakeInPlaceProvider struct {
+	machineproviders.MachineProvider
+
+	supportsInPlace bool
+	createdIndexes  []int32
+	patchedNames    []string
+}
+
+func (f *fakeInPlaceProvider) CreateMachine(_ context.Context, _ logr.Logger, index int32, _ interface{}) error {
+	f.createdIndexes = append(f.createdIndexes, index)
+	return nil
+}
+
+func (f *fakeInPlaceProvider) SupportsInPlace(_ int32) bool {
+	return f.supportsInPlace
+}
+
+func (f *fakeInPlaceProvider) PatchMachineInPlace(_ context.Context, _ logr.Logger, machineRef *machineproviders.ObjectRef, _ int32, _ interface{}) error {
+	f.patchedNames = append(f.patchedNames, machineRef.ObjectMeta.Name)
+	return nil
+}
+
+func inPlaceCPMS() *machinev1.ControlPlaneMachineSet {
+	replicas := int32(3)
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	cpms.Spec.Replicas = &replicas
+	cpms.Spec.Strategy.Type = machinev1.InPlace
+
+	return cpms
+}
+
+func updatingMachineInfo(name string) machineproviders.MachineInfo {
+	return machineproviders.MachineInfo{
+		Ready:           true,
+		InPlaceUpdating: true,
+		MachineRef: &machineproviders.ObjectRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		},
+	}
+}
+
+// TestReconcileMachineInPlaceUpdateEmptyIndex covers an index with no Machine at all: a replacement must be
+// created immediately, the same as every other strategy.
+func TestReconcileMachineInPlaceUpdateEmptyIndex(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := inPlaceCPMS()
+	provider := &fakeInPlaceProvider{supportsInPlace: true}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 1 || provider.createdIndexes[0] != 0 {
+		t.Fatalf("expected a replacement to be created for index 0, got: %v", provider.createdIndexes)
+	}
+
+	if len(provider.patchedNames) != 0 {
+		t.Fatalf("expected no patch while an index is missing its Machine, got: %v", provider.patchedNames)
+	}
+}
+
+// TestReconcileMachineInPlaceUpdateWaitsForInFlightUpdate covers one index already being patched in place:
+// only one index may be mid-update at a time, so a second, otherwise-outdated index must wait rather than
+// being patched in parallel.
+func TestReconcileMachineInPlaceUpdateWaitsForInFlightUpdate(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := inPlaceCPMS()
+	provider := &fakeInPlaceProvider{supportsInPlace: true}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {updatingMachineInfo("master-0")},
+		1: {outdatedMachineInfo("master-1", false)},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.patchedNames) != 0 {
+		t.Fatalf("expected no patch while index 0 is already mid-update, got: %v", provider.patchedNames)
+	}
+
+	if len(provider.createdIndexes) != 0 {
+		t.Fatalf("expected no Machine to be created, got: %v", provider.createdIndexes)
+	}
+}
+
+// TestReconcileMachineInPlaceUpdateUnsupportedDegrades covers a provider that cannot apply the requested
+// change in place: the ControlPlaneMachineSet must be marked Degraded rather than silently falling back to
+// replacing the Machine.
+func TestReconcileMachineInPlaceUpdateUnsupportedDegrades(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := inPlaceCPMS()
+	provider := &fakeInPlaceProvider{supportsInPlace: false}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", false)},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.patchedNames) != 0 {
+		t.Fatalf("expected no patch when in-place changes are unsupported, got: %v", provider.patchedNames)
+	}
+
+	cond := meta.FindStatusCondition(cpms.Status.Conditions, conditionDegraded)
+	if cond == nil || cond.Reason != reasonInPlaceUnsupported {
+		t.Fatalf("expected a Degraded condition with reason %q, got: %+v", reasonInPlaceUnsupported, cpms.Status.Conditions)
+	}
+}
+
+// TestReconcileMachineInPlaceUpdateSuccessfulPatch covers the common case: an outdated Machine is patched in
+// place exactly once.
+func TestReconcileMachineInPlaceUpdateSuccessfulPatch(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := inPlaceCPMS()
+	provider := &fakeInPlaceProvider{supportsInPlace: true}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", false)},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.patchedNames) != 1 || provider.patchedNames[0] != "master-0" {
+		t.Fatalf("expected master-0 to be patched in place exactly once, got: %v", provider.patchedNames)
+	}
+}
+
+// TestReconcileMachineInPlaceUpdateSteadyState covers the fully settled state: every index has a Ready,
+// up-to-date Machine. No further action should be taken.
+func TestReconcileMachineInPlaceUpdateSteadyState(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := inPlaceCPMS()
+	provider := &fakeInPlaceProvider{supportsInPlace: true}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {readyMachineInfo("master-0")},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.patchedNames) != 0 || len(provider.createdIndexes) != 0 {
+		t.Fatalf("expected no provider action in the steady state, got patches: %v, creates: %v", provider.patchedNames, provider.createdIndexes)
+	}
+}
+
+// fakeCachingInPlaceProvider is a machineproviders.MachineProvider whose PatchMachineInPlace behaves the
+// way a real provider built on the shared Server-Side Apply helpers would: it consults the passed-in
+// *ssa.Cache itself, and only counts a call as a real patch when the cache says the request is not a
+// no-op repeat of the last one it recorded.
+type fakeCachingInPlaceProvider struct {
+	machineproviders.MachineProvider
+
+	patchCalls int
+}
+
+func (f *fakeCachingInPlaceProvider) SupportsInPlace(_ int32) bool {
+	return true
+}
+
+func (f *fakeCachingInPlaceProvider) PatchMachineInPlace(_ context.Context, _ logr.Logger, machineRef *machineproviders.ObjectRef, index int32, cache interface{}) error {
+	key := ssa.Key{
+		GVK:             machineGVK,
+		Namespace:       "openshift-machine-api",
+		Name:            machineRef.ObjectMeta.Name,
+		ResourceVersion: machineRef.ObjectMeta.ResourceVersion,
+		SpecHash:        fmt.Sprintf("index-%d", index),
+	}
+
+	c, _ := cache.(*ssa.Cache)
+	if c != nil && !c.ShouldApply(key) {
+		return nil
+	}
+
+	f.patchCalls++
+
+	if c != nil {
+		c.RecordApplied(key)
+	}
+
+	return nil
+}
+
+// TestReconcileMachineInPlaceUpdateCacheSuppressesRepeatedPatch covers the acceptance criterion named by
+// the original request: wiring the reconciler's SSACache through to the machine provider, a second,
+// identical reconcile pass over the same outdated Machine must make zero further patch calls, since the
+// provider has no new change to apply.
+func TestReconcileMachineInPlaceUpdateCacheSuppressesRepeatedPatch(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	r.SSACache = ssa.NewCache()
+	cpms := inPlaceCPMS()
+	provider := &fakeCachingInPlaceProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", false)},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error on first pass: %v", err)
+	}
+
+	if provider.patchCalls != 1 {
+		t.Fatalf("expected exactly 1 patch call on the first pass, got %d", provider.patchCalls)
+	}
+
+	if _, err := r.reconcileMachineInPlaceUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error on second, identical pass: %v", err)
+	}
+
+	if provider.patchCalls != 1 {
+		t.Fatalf("expected the second, identical pass to make zero further patch calls, got %d total", provider.patchCalls)
+	}
+}
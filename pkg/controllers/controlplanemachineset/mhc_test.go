@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controlplanemachineset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// mhcSelectingMachine builds a MachineHealthCheck that selects Machines carrying the given label.
+func mhcSelectingMachine(name, labelKey, labelValue string) *machinev1beta1.MachineHealthCheck {
+	return &machinev1beta1.MachineHealthCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "openshift-machine-api"},
+		Spec: machinev1beta1.MachineHealthCheckSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{labelKey: labelValue}},
+		},
+	}
+}
+
+// TestReconcileUpgradeAnnotationsAddsAnnotationWhenSelected covers the regression that 5432d63 introduced:
+// the skip-remediation annotation must be added to a Machine participating in the rollout when a
+// MachineHealthCheck selects the ControlPlaneMachineSet's Machines, rather than whenever any MHC exists in the
+// namespace.
+func TestReconcileUpgradeAnnotationsAddsAnnotationWhenSelected(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+
+	selected := &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "master-0",
+			Namespace: r.Namespace,
+			Labels:    map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"},
+		},
+	}
+	notSelected := &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "master-1", Namespace: r.Namespace},
+	}
+
+	if err := r.Client.Create(context.Background(), selected); err != nil {
+		t.Fatalf("error creating machine: %v", err)
+	}
+
+	if err := r.Client.Create(context.Background(), notSelected); err != nil {
+		t.Fatalf("error creating machine: %v", err)
+	}
+
+	mhc := mhcSelectingMachine("master-mhc", "machine.openshift.io/cluster-api-machine-role", "master")
+	if err := r.Client.Create(context.Background(), mhc); err != nil {
+		t.Fatalf("error creating machine health check: %v", err)
+	}
+
+	selectedInfo := machineproviders.MachineInfo{
+		NeedsUpdate: true,
+		MachineRef:  &machineproviders.ObjectRef{ObjectMeta: selected.ObjectMeta},
+	}
+	notSelectedInfo := machineproviders.MachineInfo{
+		Ready:      true,
+		MachineRef: &machineproviders.ObjectRef{ObjectMeta: notSelected.ObjectMeta},
+	}
+
+	sortedIndexedMs := [][]machineproviders.MachineInfo{{selectedInfo}, {notSelectedInfo}}
+	tracker := buildUpgradeTracker(sortedIndexedMs)
+
+	if err := r.reconcileUpgradeAnnotations(context.Background(), logr.Discard(), cpms, sortedIndexedMs, tracker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := &machinev1beta1.Machine{}
+	if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(selected), got); err != nil {
+		t.Fatalf("error fetching machine: %v", err)
+	}
+
+	if _, ok := got.Annotations[skipRemediationAnnotation]; !ok {
+		t.Fatalf("expected the selected, rolling machine to carry %q, got annotations: %v", skipRemediationAnnotation, got.Annotations)
+	}
+}
+
+// TestReconcileUpgradeAnnotationsCleansUpStaleAnnotation covers the other half of the same regression: once
+// a Machine has settled into the updated set, a skip-remediation annotation left over from an earlier
+// MachineHealthCheck that has since been deleted or re-scoped must be removed, not leaked forever.
+func TestReconcileUpgradeAnnotationsCleansUpStaleAnnotation(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+
+	settled := &machinev1beta1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "master-0",
+			Namespace:   r.Namespace,
+			Annotations: map[string]string{skipRemediationAnnotation: ""},
+		},
+	}
+
+	if err := r.Client.Create(context.Background(), settled); err != nil {
+		t.Fatalf("error creating machine: %v", err)
+	}
+
+	// No MachineHealthCheck exists any more: it was deleted or re-scoped after this Machine was annotated.
+	settledInfo := machineproviders.MachineInfo{
+		Ready:      true,
+		MachineRef: &machineproviders.ObjectRef{ObjectMeta: settled.ObjectMeta},
+	}
+
+	sortedIndexedMs := [][]machineproviders.MachineInfo{{settledInfo}}
+	tracker := buildUpgradeTracker(sortedIndexedMs)
+
+	if err := r.reconcileUpgradeAnnotations(context.Background(), logr.Discard(), cpms, sortedIndexedMs, tracker); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := &machinev1beta1.Machine{}
+	if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(settled), got); err != nil {
+		t.Fatalf("error fetching machine: %v", err)
+	}
+
+	if _, ok := got.Annotations[skipRemediationAnnotation]; ok {
+		t.Fatalf("expected the stale %q annotation to be cleaned up, got annotations: %v", skipRemediationAnnotation, got.Annotations)
+	}
+}
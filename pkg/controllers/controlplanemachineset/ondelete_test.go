@@ -0,0 +1,213 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controlplanemachineset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeOnDeleteProvider is a minimal machineproviders.MachineProvider that only records which indexes it was asked
+// to create a replacement Machine for, for use by the reconcileMachineOnDeleteUpdate tests below.
+type fakeOnDeleteProvider struct {
+	machineproviders.MachineProvider
+
+	createdIndexes []int32
+}
+
+func (f *fakeOnDeleteProvider) CreateMachine(_ context.Context, _ logr.Logger, index int32, _ interface{}) error {
+	f.createdIndexes = append(f.createdIndexes, index)
+	return nil
+}
+
+func newOnDeleteTestReconciler(t *testing.T) *ControlPlaneMachineSetReconciler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := machinev1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("error building scheme: %v", err)
+	}
+
+	return &ControlPlaneMachineSetReconciler{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Namespace: "openshift-machine-api",
+	}
+}
+
+func onDeleteCPMS() *machinev1.ControlPlaneMachineSet {
+	replicas := int32(3)
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	cpms.Spec.Replicas = &replicas
+
+	return cpms
+}
+
+func readyMachineInfo(name string) machineproviders.MachineInfo {
+	return machineproviders.MachineInfo{
+		Ready: true,
+		MachineRef: &machineproviders.ObjectRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		},
+	}
+}
+
+func outdatedMachineInfo(name string, deleted bool) machineproviders.MachineInfo {
+	mi := machineproviders.MachineInfo{
+		Ready:       true,
+		NeedsUpdate: true,
+		MachineRef: &machineproviders.ObjectRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		},
+	}
+
+	if deleted {
+		now := metav1.NewTime(time.Unix(0, 0))
+		mi.MachineRef.ObjectMeta.DeletionTimestamp = &now
+	}
+
+	return mi
+}
+
+func pendingMachineInfo(name string) machineproviders.MachineInfo {
+	return machineproviders.MachineInfo{
+		Ready: false,
+		MachineRef: &machineproviders.ObjectRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		},
+	}
+}
+
+// TestReconcileMachineOnDeleteUpdateEmptyIndex covers an index with no Machine at all: a replacement must be
+// created immediately.
+func TestReconcileMachineOnDeleteUpdateEmptyIndex(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+	provider := &fakeOnDeleteProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineOnDeleteUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 1 || provider.createdIndexes[0] != 0 {
+		t.Fatalf("expected a replacement to be created for index 0, got: %v", provider.createdIndexes)
+	}
+}
+
+// TestReconcileMachineOnDeleteUpdatePendingReplacement covers an index where the user has already deleted the
+// outdated Machine and a replacement has been created, but has not yet become Ready: no further action is taken
+// until the replacement settles.
+func TestReconcileMachineOnDeleteUpdatePendingReplacement(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+	provider := &fakeOnDeleteProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", true), pendingMachineInfo("master-0-replacement")},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineOnDeleteUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 0 {
+		t.Fatalf("expected no Machine to be created while waiting for the replacement, got: %v", provider.createdIndexes)
+	}
+}
+
+// TestReconcileMachineOnDeleteUpdateDeletedAwaitingReplacement covers an index where the user has deleted the
+// outdated Machine but no replacement has been created yet: a replacement is created exactly once.
+func TestReconcileMachineOnDeleteUpdateDeletedAwaitingReplacement(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+	provider := &fakeOnDeleteProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", true)},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineOnDeleteUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 1 || provider.createdIndexes[0] != 0 {
+		t.Fatalf("expected a replacement to be created for index 0, got: %v", provider.createdIndexes)
+	}
+}
+
+// TestReconcileMachineOnDeleteUpdateOutdatedNotDeleted covers an outdated Machine that the user has not yet
+// deleted: the strategy must wait for the user to act, and must not create or delete anything itself.
+func TestReconcileMachineOnDeleteUpdateOutdatedNotDeleted(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+	provider := &fakeOnDeleteProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", false)},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineOnDeleteUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 0 {
+		t.Fatalf("expected no provider action until the user deletes the outdated Machine, got: %v", provider.createdIndexes)
+	}
+}
+
+// TestReconcileMachineOnDeleteUpdateSteadyState covers the fully settled state: every index has a Ready,
+// up-to-date Machine and the outdated Machine has already been deleted and replaced. No further action should be
+// taken.
+func TestReconcileMachineOnDeleteUpdateSteadyState(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := onDeleteCPMS()
+	provider := &fakeOnDeleteProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {readyMachineInfo("master-0")},
+		1: {readyMachineInfo("master-1")},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineOnDeleteUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 0 {
+		t.Fatalf("expected no provider action in the steady state, got: %v", provider.createdIndexes)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controlplanemachineset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	machinev1 "github.com/openshift/api/machine/v1"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// fakeRemediationProvider is a minimal machineproviders.MachineProvider that only records which
+// Machines it was asked to delete, for use by the reconcileMachineRemediation tests below.
+type fakeRemediationProvider struct {
+	machineproviders.MachineProvider
+
+	deletedNames []string
+}
+
+func (f *fakeRemediationProvider) DeleteMachine(_ context.Context, _ logr.Logger, ref *machineproviders.ObjectRef, _ interface{}) error {
+	f.deletedNames = append(f.deletedNames, ref.ObjectMeta.Name)
+	return nil
+}
+
+func machineInfoFor(name string, unhealthy bool) machineproviders.MachineInfo {
+	return machineproviders.MachineInfo{
+		Ready: true,
+		MachineRef: &machineproviders.ObjectRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		},
+		Unhealthy: unhealthy,
+	}
+}
+
+// unhealthySinceMachineInfo builds a MachineInfo that has been continuously unhealthy since unhealthySince,
+// for use by the MinHealthyPeriod gating tests below.
+func unhealthySinceMachineInfo(name string, unhealthySince time.Time) machineproviders.MachineInfo {
+	since := metav1.NewTime(unhealthySince)
+
+	return machineproviders.MachineInfo{
+		Ready: true,
+		MachineRef: &machineproviders.ObjectRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+		},
+		Unhealthy:      true,
+		UnhealthySince: &since,
+	}
+}
+
+func cpmsWithReplicasAndMaxInFlight(replicas int32, maxInFlight *intstr.IntOrString) *machinev1.ControlPlaneMachineSet {
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	cpms.Spec.Replicas = &replicas
+
+	if maxInFlight != nil {
+		cpms.Spec.Remediation = &machinev1.ControlPlaneMachineSetRemediation{MaxInFlight: maxInFlight}
+	}
+
+	return cpms
+}
+
+// TestReconcileMachineRemediationSingleUnhealthy covers the most basic scenario the request calls
+// out: a 3 replica control plane with a single unhealthy Machine should be remediated.
+func TestReconcileMachineRemediationSingleUnhealthy(t *testing.T) {
+	cpms := cpmsWithReplicasAndMaxInFlight(3, nil)
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {machineInfoFor("master-0", true)},
+		1: {machineInfoFor("master-1", false)},
+		2: {machineInfoFor("master-2", false)},
+	}
+	provider := &fakeRemediationProvider{}
+
+	r := &ControlPlaneMachineSetReconciler{}
+
+	if _, err := r.reconcileMachineRemediation(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.deletedNames) != 1 || provider.deletedNames[0] != "master-0" {
+		t.Fatalf("expected master-0 to be remediated, got deletions: %v", provider.deletedNames)
+	}
+}
+
+// TestReconcileMachineRemediationMaxInFlight covers two simultaneously unhealthy Machines with
+// MaxInFlight=1: only one may be remediated at a time.
+func TestReconcileMachineRemediationMaxInFlight(t *testing.T) {
+	maxInFlight := intstr.FromInt(1)
+	cpms := cpmsWithReplicasAndMaxInFlight(5, &maxInFlight)
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {machineInfoFor("master-0", true)},
+		1: {machineInfoFor("master-1", true)},
+		2: {machineInfoFor("master-2", false)},
+		3: {machineInfoFor("master-3", false)},
+		4: {machineInfoFor("master-4", false)},
+	}
+	provider := &fakeRemediationProvider{}
+
+	r := &ControlPlaneMachineSetReconciler{}
+
+	if _, err := r.reconcileMachineRemediation(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.deletedNames) != 1 {
+		t.Fatalf("expected exactly 1 machine to be remediated, got: %v", provider.deletedNames)
+	}
+}
+
+// TestReconcileMachineRemediationBlockedByQuorum covers a scenario where remediating the unhealthy
+// Machine would drop the control plane below etcd quorum, and so must not proceed.
+func TestReconcileMachineRemediationBlockedByQuorum(t *testing.T) {
+	cpms := cpmsWithReplicasAndMaxInFlight(3, nil)
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {machineInfoFor("master-0", true)},
+		1: {machineInfoFor("master-1", true)},
+		2: {machineInfoFor("master-2", false)},
+	}
+	provider := &fakeRemediationProvider{}
+
+	r := &ControlPlaneMachineSetReconciler{}
+
+	if _, err := r.reconcileMachineRemediation(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.deletedNames) != 0 {
+		t.Fatalf("expected remediation to be blocked by quorum, got deletions: %v", provider.deletedNames)
+	}
+}
+
+// TestReconcileMachineRemediationDeferredWithinMinHealthyPeriod covers a Machine that has only just gone
+// unhealthy: with a configured MinHealthyPeriod it must not be remediated until it has stayed unhealthy for
+// at least that long.
+func TestReconcileMachineRemediationDeferredWithinMinHealthyPeriod(t *testing.T) {
+	cpms := cpmsWithReplicasAndMaxInFlight(3, nil)
+	cpms.Spec.Remediation = &machinev1.ControlPlaneMachineSetRemediation{MinHealthyPeriod: &metav1.Duration{Duration: 5 * time.Minute}}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {unhealthySinceMachineInfo("master-0", time.Now().Add(-time.Minute))},
+		1: {machineInfoFor("master-1", false)},
+		2: {machineInfoFor("master-2", false)},
+	}
+	provider := &fakeRemediationProvider{}
+
+	r := &ControlPlaneMachineSetReconciler{}
+
+	if _, err := r.reconcileMachineRemediation(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.deletedNames) != 0 {
+		t.Fatalf("expected remediation to be deferred within MinHealthyPeriod, got deletions: %v", provider.deletedNames)
+	}
+}
+
+// TestReconcileMachineRemediationProceedsAfterMinHealthyPeriod covers a Machine that has been unhealthy for
+// longer than the configured MinHealthyPeriod: remediation must proceed.
+func TestReconcileMachineRemediationProceedsAfterMinHealthyPeriod(t *testing.T) {
+	cpms := cpmsWithReplicasAndMaxInFlight(3, nil)
+	cpms.Spec.Remediation = &machinev1.ControlPlaneMachineSetRemediation{MinHealthyPeriod: &metav1.Duration{Duration: 5 * time.Minute}}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {unhealthySinceMachineInfo("master-0", time.Now().Add(-10*time.Minute))},
+		1: {machineInfoFor("master-1", false)},
+		2: {machineInfoFor("master-2", false)},
+	}
+	provider := &fakeRemediationProvider{}
+
+	r := &ControlPlaneMachineSetReconciler{}
+
+	if _, err := r.reconcileMachineRemediation(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.deletedNames) != 1 || provider.deletedNames[0] != "master-0" {
+		t.Fatalf("expected master-0 to be remediated once past MinHealthyPeriod, got: %v", provider.deletedNames)
+	}
+}
+
+func TestCountHealthyMachines(t *testing.T) {
+	mis := [][]machineproviders.MachineInfo{
+		{machineInfoFor("master-0", true)},
+		{machineInfoFor("master-1", false)},
+		{machineInfoFor("master-2", false)},
+	}
+
+	if got := countHealthyMachines(mis); got != 2 {
+		t.Fatalf("expected 2 healthy machines, got %d", got)
+	}
+}
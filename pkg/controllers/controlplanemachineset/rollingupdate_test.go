@@ -0,0 +1,97 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controlplanemachineset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	machinev1 "github.com/openshift/api/machine/v1"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// fakeRollingUpdateProvider is a minimal machineproviders.MachineProvider that only records which indexes it was
+// asked to create a replacement Machine for, for use by the reconcileMachineRollingUpdate tests below.
+type fakeRollingUpdateProvider struct {
+	machineproviders.MachineProvider
+
+	createdIndexes []int32
+}
+
+func (f *fakeRollingUpdateProvider) CreateMachine(_ context.Context, _ logr.Logger, index int32, _ interface{}) error {
+	f.createdIndexes = append(f.createdIndexes, index)
+	return nil
+}
+
+func rollingUpdateCPMS(replicas int32, maxSurge int) *machinev1.ControlPlaneMachineSet {
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	cpms.Spec.Replicas = &replicas
+
+	surge := intstr.FromInt(maxSurge)
+	cpms.Spec.Strategy.RollingUpdate = &machinev1.RollingUpdateControlPlaneMachineSetStrategy{MaxSurge: &surge}
+
+	return cpms
+}
+
+// TestReconcileMachineRollingUpdateParallelAcrossIndexes covers maxSurge=2 with two indexes simultaneously
+// outdated: index 0 already has a pending, not-yet-ready surge replacement, and index 1 has no replacement at all
+// yet. Index 0 being mid-surge must not block index 1 from being surged too, since the configured maxSurge
+// allows both to be in flight at once.
+func TestReconcileMachineRollingUpdateParallelAcrossIndexes(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := rollingUpdateCPMS(3, 2)
+	provider := &fakeRollingUpdateProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", false), pendingMachineInfo("master-0-replacement")},
+		1: {outdatedMachineInfo("master-1", false)},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineRollingUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 1 || provider.createdIndexes[0] != 1 {
+		t.Fatalf("expected index 1 to be surged in parallel with index 0's in-flight replacement, got: %v", provider.createdIndexes)
+	}
+}
+
+// TestReconcileMachineRollingUpdateRespectsMaxSurgeCap covers the case where the configured maxSurge has already
+// been reached by Machines surged for other indexes: a further index needing an update must wait rather than
+// exceed the configured surge budget.
+func TestReconcileMachineRollingUpdateRespectsMaxSurgeCap(t *testing.T) {
+	r := newOnDeleteTestReconciler(t)
+	cpms := rollingUpdateCPMS(3, 1)
+	provider := &fakeRollingUpdateProvider{}
+
+	machineInfos := map[int32][]machineproviders.MachineInfo{
+		0: {outdatedMachineInfo("master-0", false), pendingMachineInfo("master-0-replacement")},
+		1: {outdatedMachineInfo("master-1", false)},
+		2: {readyMachineInfo("master-2")},
+	}
+
+	if _, err := r.reconcileMachineRollingUpdate(context.Background(), logr.Discard(), cpms, provider, machineInfos); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(provider.createdIndexes) != 0 {
+		t.Fatalf("expected no further surge once maxSurge is already exhausted, got: %v", provider.createdIndexes)
+	}
+}
@@ -21,20 +21,47 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
 	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/machineproviders"
+	"github.com/openshift/cluster-control-plane-machine-set-operator/pkg/util/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// defaultMaxSurge is the number of Machines that may be surged above spec.replicas when the user
+// has not configured spec.strategy.rollingUpdate.maxSurge.
+const defaultMaxSurge = 1
+
+// defaultMinHealthyPeriod is the minimum amount of time a Machine must have been continuously unhealthy
+// before it is remediated, when the user has not configured spec.remediation.minHealthyPeriod. It exists
+// so that a Machine which fails a health check only transiently, and recovers on its own shortly after, is
+// not needlessly replaced.
+const defaultMinHealthyPeriod = 5 * time.Minute
+
+// skipRemediationAnnotation is added to a Machine while it is participating in a rollout (pending
+// replacement, surged extra, or outdated Machine awaiting removal), so that a MachineHealthCheck
+// selecting it does not race the rollout by remediating it concurrently. It is removed once the
+// Machine settles into the set of updated Machines, or is removed entirely.
+const skipRemediationAnnotation = "machine.openshift.io/skip-remediation"
+
 const (
 	// createdReplacement is a log message used to inform the user that a new Machine was created to
 	// replace an existing Machine.
 	createdReplacement = "Created replacement machine"
 
+	// errorAnnotatingMachine is a log message used to inform the user that an error occurred while
+	// adding the skip-remediation annotation to a Machine participating in a rollout.
+	errorAnnotatingMachine = "Error annotating machine to pause remediation"
+
 	// errorCreatingMachine is a log message used to inform the user that an error occurred while
 	// attempting to create a replacement Machine.
 	errorCreatingMachine = "Error creating machine"
@@ -43,6 +70,18 @@ const (
 	// attempting to delete replacement Machine.
 	errorDeletingMachine = "Error deleting machine"
 
+	// errorPatchingMachine is a log message used to inform the user that an error occurred while
+	// attempting to patch a Machine in place. This is used with the InPlace replacement strategy.
+	errorPatchingMachine = "Error patching machine in place"
+
+	// errorRemovingAnnotation is a log message used to inform the user that an error occurred while
+	// removing the skip-remediation annotation from a Machine that has settled after a rollout.
+	errorRemovingAnnotation = "Error removing skip-remediation annotation from machine"
+
+	// inPlaceUnsupportedMessage is used to inform the user that the machine provider does not support
+	// mutating the requested change to the Machine's provider spec in place.
+	inPlaceUnsupportedMessage = "machine provider does not support the requested change in place"
+
 	// invalidStrategyMessage is used to inform the user that they have provided an invalid value
 	// for the update strategy.
 	invalidStrategyMessage = "invalid value for spec.strategy.type"
@@ -56,6 +95,55 @@ const (
 	// the current set of Machines.
 	noUpdatesRequired = "No updates required"
 
+	// patchedMachineInPlace is a log message used to inform the user that a Machine was patched in
+	// place to apply an update, rather than being replaced. This is used with the InPlace replacement
+	// strategy.
+	patchedMachineInPlace = "Patched machine in place"
+
+	// reasonInPlaceUnsupported is a condition reason used to inform the user that the InPlace update
+	// strategy could not be applied because the machine provider does not support the requested
+	// change to the Machine's provider spec in place.
+	reasonInPlaceUnsupported = "InPlaceUnsupported"
+
+	// reasonRemediationBlockedByQuorum is a condition reason used to inform the user that an unhealthy
+	// Machine has not been remediated because doing so would drop the number of healthy Machines
+	// below etcd quorum.
+	reasonRemediationBlockedByQuorum = "RemediationBlockedByQuorum"
+
+	// reasonRemediationBudgetExhausted is a condition reason used to inform the user that an unhealthy
+	// Machine has not been remediated because spec.remediation.maxInFlight remediations are already
+	// in flight.
+	reasonRemediationBudgetExhausted = "RemediationBudgetExhausted"
+
+	// reasonRemediationDeferred is a condition reason used to inform the user that an unhealthy Machine
+	// has not yet been remediated because it has not been continuously unhealthy for
+	// spec.remediation.minHealthyPeriod.
+	reasonRemediationDeferred = "RemediationDeferred"
+
+	// reasonRemediatingMachine is a condition reason used to inform the user that an unhealthy Machine
+	// is currently being remediated.
+	reasonRemediatingMachine = "RemediatingMachine"
+
+	// reasonUpgrading is a condition reason used to inform MachineHealthCheck controllers, and users,
+	// that a rollout is in progress.
+	reasonUpgrading = "Upgrading"
+
+	// remediatingMachineMessage is a log message used to inform the user that an unhealthy Machine is
+	// being deleted so that it can be replaced.
+	remediatingMachineMessage = "Remediating unhealthy machine"
+
+	// remediationBlockedByQuorumMessage is a log message used to inform the user that remediation of
+	// an unhealthy Machine is being withheld to avoid breaching etcd quorum.
+	remediationBlockedByQuorumMessage = "Remediation blocked: removing this machine would breach etcd quorum"
+
+	// remediationBudgetExhaustedMessage is a log message used to inform the user that no further
+	// Machines can be remediated until an in-flight remediation completes.
+	remediationBudgetExhaustedMessage = "Remediation budget exhausted, waiting for in-flight remediation to complete"
+
+	// remediationDeferredMessage is a log message used to inform the user that an unhealthy Machine is
+	// not yet being remediated because it has not been unhealthy for long enough.
+	remediationDeferredMessage = "Remediation deferred: machine has not been continuously unhealthy for spec.remediation.minHealthyPeriod yet"
+
 	// removingOldMachine is a log message used to inform the user that an old Machine has been
 	// deleted as a part of the rollout operation.
 	removingOldMachine = "Removing old machine"
@@ -76,6 +164,10 @@ const (
 )
 
 var (
+	// errInPlaceUnsupported is used to inform users that the machine provider does not support the
+	// requested change to a Machine's provider spec in place.
+	errInPlaceUnsupported = errors.New(inPlaceUnsupportedMessage)
+
 	// errRecreateStrategyNotSupported is used to inform users that the Recreate update strategy is not yet supported.
 	// It may be supported in a future version.
 	errRecreateStrategyNotSupported = fmt.Errorf("update strategy %q is not supported", machinev1.Recreate)
@@ -97,6 +189,8 @@ func (r *ControlPlaneMachineSetReconciler) reconcileMachineUpdates(ctx context.C
 		return r.reconcileMachineRollingUpdate(ctx, logger, cpms, machineProvider, machineInfos)
 	case machinev1.OnDelete:
 		return r.reconcileMachineOnDeleteUpdate(ctx, logger, cpms, machineProvider, machineInfos)
+	case machinev1.InPlace:
+		return r.reconcileMachineInPlaceUpdate(ctx, logger, cpms, machineProvider, machineInfos)
 	case machinev1.Recreate:
 		meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
 			Type:    conditionDegraded,
@@ -123,6 +217,334 @@ func (r *ControlPlaneMachineSetReconciler) reconcileMachineUpdates(ctx context.C
 	return ctrl.Result{}, nil
 }
 
+// UpgradeTracker accumulates, for a single reconcile pass, the Machines that are currently participating in a
+// rollout: pending replacements, surged extras, and outdated Machines still awaiting removal. It exists so that,
+// even when the configured MaxSurge allows more than one index to be mid-rollout within a single pass, every
+// participating Machine can be annotated to pause MachineHealthCheck remediation atomically, before any create or
+// delete call reaches the machine provider.
+type UpgradeTracker struct {
+	// rollingMachines holds the Machines currently participating in the rollout.
+	rollingMachines []machineproviders.MachineInfo
+}
+
+// addRollingMachine records a Machine as participating in the current rollout.
+func (u *UpgradeTracker) addRollingMachine(m machineproviders.MachineInfo) {
+	u.rollingMachines = append(u.rollingMachines, m)
+}
+
+// buildUpgradeTracker collects every Machine, across all indexes, that is currently participating in an
+// in-progress rollout: a Machine that needs an update (whether or not it has been deleted yet), or a Machine that is
+// not yet Ready (a pending replacement or a surged extra).
+func buildUpgradeTracker(mis [][]machineproviders.MachineInfo) *UpgradeTracker {
+	tracker := &UpgradeTracker{}
+
+	for _, machines := range mis {
+		for _, m := range machines {
+			if m.NeedsUpdate || !m.Ready {
+				tracker.addRollingMachine(m)
+			}
+		}
+	}
+
+	return tracker
+}
+
+// reconcileUpgradeAnnotations ensures that every Machine recorded in the UpgradeTracker carries
+// skipRemediationAnnotation, and that the annotation is removed from any Machine that has settled into the set of
+// updated Machines. Annotating is skipped when no MachineHealthCheck selects the ControlPlaneMachineSet's Machines,
+// since there is then nothing for the rollout to race with, but the cleanup of settled Machines always runs
+// regardless, so that an annotation left over from an MHC that has since been deleted or re-scoped is not leaked
+// forever.
+func (r *ControlPlaneMachineSetReconciler) reconcileUpgradeAnnotations(ctx context.Context, logger logr.Logger, cpms *machinev1.ControlPlaneMachineSet, sortedIndexedMs [][]machineproviders.MachineInfo, tracker *UpgradeTracker) error {
+	mhcList := &machinev1beta1.MachineHealthCheckList{}
+	if err := r.Client.List(ctx, mhcList, client.InNamespace(r.Namespace)); err != nil {
+		return fmt.Errorf("error listing machine health checks: %w", err)
+	}
+
+	if !hasAny(tracker.rollingMachines) || !selectingMachineHealthChecks(mhcList.Items, sortedIndexedMs) {
+		// No rollout is pausing remediation for these Machines, so the Upgrading condition does not apply.
+		meta.RemoveStatusCondition(&cpms.Status.Conditions, conditionUpgrading)
+	} else {
+		meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
+			Type:   conditionUpgrading,
+			Status: metav1.ConditionTrue,
+			Reason: reasonUpgrading,
+		})
+
+		for _, rollingMachine := range tracker.rollingMachines {
+			name := rollingMachine.MachineRef.ObjectMeta.Name
+			if _, ok := rollingMachine.MachineRef.ObjectMeta.Annotations[skipRemediationAnnotation]; ok {
+				continue
+			}
+
+			if err := r.annotateMachine(ctx, name, skipRemediationAnnotation); err != nil {
+				logger.WithValues("name", name).Error(err, errorAnnotatingMachine)
+				return err
+			}
+		}
+	}
+
+	// Cleanup always runs, even when no MachineHealthCheck currently selects these Machines: an MHC that
+	// selected them earlier in the rollout may have been deleted or re-scoped since, and a settled Machine
+	// should never be left carrying a stale skipRemediationAnnotation.
+	for _, machines := range sortedIndexedMs {
+		for _, settled := range updatedMachines(machines) {
+			name := settled.MachineRef.ObjectMeta.Name
+			if _, ok := settled.MachineRef.ObjectMeta.Annotations[skipRemediationAnnotation]; !ok {
+				continue
+			}
+
+			if err := r.removeMachineAnnotation(ctx, name, skipRemediationAnnotation); err != nil {
+				logger.WithValues("name", name).Error(err, errorRemovingAnnotation)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectingMachineHealthChecks reports whether any of the given MachineHealthChecks selects at least one of the
+// Machines in sortedIndexedMs, by matching each MachineHealthCheck's label selector against the Machine's labels.
+// An MHC that selects no Machine belonging to this ControlPlaneMachineSet cannot race with its rollout, so it is
+// ignored rather than pausing remediation for every Machine in the namespace.
+func selectingMachineHealthChecks(mhcs []machinev1beta1.MachineHealthCheck, sortedIndexedMs [][]machineproviders.MachineInfo) bool {
+	for _, mhc := range mhcs {
+		selector, err := metav1.LabelSelectorAsSelector(&mhc.Spec.Selector)
+		if err != nil {
+			continue
+		}
+
+		for _, machines := range sortedIndexedMs {
+			for _, m := range machines {
+				if selector.Matches(labels.Set(m.MachineRef.ObjectMeta.Labels)) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// annotateMachine ensures the named Machine carries the given annotation, patching it if required.
+func (r *ControlPlaneMachineSetReconciler) annotateMachine(ctx context.Context, name, key string) error {
+	machine := &machinev1beta1.Machine{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: name}, machine); err != nil {
+		return fmt.Errorf("error fetching machine %s/%s: %w", r.Namespace, name, err)
+	}
+
+	if _, ok := machine.Annotations[key]; ok {
+		return nil
+	}
+
+	patch := client.MergeFrom(machine.DeepCopy())
+
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+
+	machine.Annotations[key] = ""
+
+	if err := r.Client.Patch(ctx, machine, patch); err != nil {
+		return fmt.Errorf("error annotating machine %s/%s: %w", r.Namespace, name, err)
+	}
+
+	return nil
+}
+
+// removeMachineAnnotation removes the given annotation from the named Machine, if present.
+func (r *ControlPlaneMachineSetReconciler) removeMachineAnnotation(ctx context.Context, name, key string) error {
+	machine := &machinev1beta1.Machine{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: name}, machine); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The Machine is already gone, there is nothing left to clean up.
+			return nil
+		}
+
+		return fmt.Errorf("error fetching machine %s/%s: %w", r.Namespace, name, err)
+	}
+
+	if _, ok := machine.Annotations[key]; !ok {
+		return nil
+	}
+
+	patch := client.MergeFrom(machine.DeepCopy())
+	delete(machine.Annotations, key)
+
+	if err := r.Client.Patch(ctx, machine, patch); err != nil {
+		return fmt.Errorf("error removing annotation from machine %s/%s: %w", r.Namespace, name, err)
+	}
+
+	return nil
+}
+
+// reconcileMachineRemediation remediates unhealthy control plane Machines by deleting them so that the update
+// strategy can create a replacement. It is invoked from the top-level Reconcile function before
+// reconcileMachineUpdates, so that an unhealthy Machine is removed before the update strategy considers what
+// further changes are required this pass.
+//
+// Remediation operates across the whole ControlPlaneMachineSet, rather than per index: at most
+// spec.remediation.maxInFlight unhealthy Machines may be deleted for remediation at any one time, and an unhealthy
+// Machine already marked for deletion counts as in flight. Remediation is also gated on etcd quorum: a Machine is
+// only deleted if doing so would leave at least a quorum of healthy Machines for spec.replicas. An unhealthy Machine
+// is further required to have been continuously unhealthy for spec.remediation.minHealthyPeriod before it is
+// remediated, so that a Machine recovering from a brief, transient failure is not needlessly replaced. The deletion
+// performed here counts towards deviseExistingSurge in the same way any other deletion does, since the update
+// strategy will need to create a replacement for the index.
+func (r *ControlPlaneMachineSetReconciler) reconcileMachineRemediation(ctx context.Context, logger logr.Logger, cpms *machinev1.ControlPlaneMachineSet, machineProvider machineproviders.MachineProvider, indexedMachineInfos map[int32][]machineproviders.MachineInfo) (ctrl.Result, error) {
+	logger = logger.WithValues("phase", "Remediation")
+
+	sortedIndexedMs := sortMachineInfos(indexedMachineInfos)
+
+	maxInFlight := effectiveMaxInFlight(cpms)
+	inFlight := remediationsInFlight(sortedIndexedMs)
+
+	if inFlight >= maxInFlight {
+		meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
+			Type:    conditionRemediating,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonRemediationBudgetExhausted,
+			Message: remediationBudgetExhaustedMessage,
+		})
+
+		logger.WithValues("maxInFlight", maxInFlight, "inFlight", inFlight).V(2).Info(remediationBudgetExhaustedMessage)
+
+		return ctrl.Result{}, nil
+	}
+
+	quorum := int(*cpms.Spec.Replicas)/2 + 1
+	healthyCount := countHealthyMachines(sortedIndexedMs)
+	minHealthyPeriod := effectiveMinHealthyPeriod(cpms)
+
+	for idx, machines := range sortedIndexedMs {
+		for _, unhealthyMachine := range unhealthyMachines(machines) {
+			if isDeletedMachine(unhealthyMachine) {
+				// Already being remediated, and already counted in the in-flight budget above.
+				continue
+			}
+
+			logger := logger.WithValues("index", idx, "namespace", r.Namespace, "name", unhealthyMachine.MachineRef.ObjectMeta.Name, "unhealthyReason", unhealthyMachine.UnhealthyReason)
+
+			if unhealthyMachine.UnhealthySince != nil && time.Since(unhealthyMachine.UnhealthySince.Time) < minHealthyPeriod {
+				meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
+					Type:    conditionRemediating,
+					Status:  metav1.ConditionTrue,
+					Reason:  reasonRemediationDeferred,
+					Message: remediationDeferredMessage,
+				})
+
+				logger.V(2).Info(remediationDeferredMessage)
+
+				continue
+			}
+
+			// countHealthyMachines already excludes this unhealthy Machine, so healthyCount is the
+			// number of Machines that would remain healthy once it is removed.
+			if healthyCount < quorum {
+				// Removing this Machine would breach etcd quorum for the remaining Machines, so
+				// remediation must wait.
+				meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
+					Type:    conditionRemediating,
+					Status:  metav1.ConditionTrue,
+					Reason:  reasonRemediationBlockedByQuorum,
+					Message: remediationBlockedByQuorumMessage,
+				})
+
+				logger.V(2).Info(remediationBlockedByQuorumMessage)
+
+				return ctrl.Result{}, nil
+			}
+
+			meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
+				Type:    conditionRemediating,
+				Status:  metav1.ConditionTrue,
+				Reason:  reasonRemediatingMachine,
+				Message: remediatingMachineMessage,
+			})
+
+			logger.V(2).Info(remediatingMachineMessage)
+
+			return deleteMachine(ctx, logger, machineProvider, unhealthyMachine, r.Namespace, idx, r.SSACache)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// effectiveMaxInFlight computes the maximum number of unhealthy Machines that may be remediated at once for the
+// ControlPlaneMachineSet. When spec.remediation or spec.remediation.maxInFlight is unset, it defaults to
+// defaultMaxSurge, mirroring the default surge of a single Machine at a time.
+func effectiveMaxInFlight(cpms *machinev1.ControlPlaneMachineSet) int {
+	remediation := cpms.Spec.Remediation
+	if remediation == nil || remediation.MaxInFlight == nil {
+		return defaultMaxSurge
+	}
+
+	maxInFlight, err := intstr.GetScaledValueFromIntOrPercent(remediation.MaxInFlight, int(*cpms.Spec.Replicas), true)
+	if err != nil {
+		return defaultMaxSurge
+	}
+
+	return maxInFlight
+}
+
+// effectiveMinHealthyPeriod computes the minimum amount of time a Machine must have been continuously
+// unhealthy before remediation will act on it. When spec.remediation or spec.remediation.minHealthyPeriod
+// is unset, it defaults to defaultMinHealthyPeriod.
+func effectiveMinHealthyPeriod(cpms *machinev1.ControlPlaneMachineSet) time.Duration {
+	remediation := cpms.Spec.Remediation
+	if remediation == nil || remediation.MinHealthyPeriod == nil {
+		return defaultMinHealthyPeriod
+	}
+
+	return remediation.MinHealthyPeriod.Duration
+}
+
+// remediationsInFlight counts the Machines, across all indexes, that are unhealthy and already marked for deletion.
+func remediationsInFlight(mis [][]machineproviders.MachineInfo) int {
+	inFlight := 0
+
+	for _, machines := range mis {
+		for _, m := range unhealthyMachines(machines) {
+			if isDeletedMachine(m) {
+				inFlight++
+			}
+		}
+	}
+
+	return inFlight
+}
+
+// countHealthyMachines counts the Machines, across all indexes, that are not flagged as unhealthy and not already
+// marked for deletion.
+func countHealthyMachines(mis [][]machineproviders.MachineInfo) int {
+	healthy := 0
+
+	for _, machines := range mis {
+		for _, m := range machines {
+			if !m.Unhealthy && !isDeletedMachine(m) {
+				healthy++
+			}
+		}
+	}
+
+	return healthy
+}
+
+// unhealthyMachines returns the list of MachineInfo which have a Machine flagged as unhealthy.
+func unhealthyMachines(machinesInfo []machineproviders.MachineInfo) []machineproviders.MachineInfo {
+	result := []machineproviders.MachineInfo{}
+
+	for i := range machinesInfo {
+		if machinesInfo[i].Unhealthy {
+			result = append(result, machinesInfo[i])
+		}
+	}
+
+	return result
+}
+
 // reconcileMachineRollingUpdate implements the rolling update strategy for the ControlPlaneMachineSet. It uses the
 // indexed machine information to determine when a new Machine is required to be created. When a new Machine is required,
 // it uses the machine provider to create the new Machine.
@@ -130,7 +552,9 @@ func (r *ControlPlaneMachineSetReconciler) reconcileMachineUpdates(ctx context.C
 // For rolling updates, a new Machine is required when a machine index has a Machine, which needs an update, but does
 // not yet have replacement created. It must also observe the surge semantics of a rolling update, so, if an existing
 // index is already going through the process of a rolling update, it should not start the update of any other index.
-// At present, the surge is limited to a single Machine instance.
+// The surge defaults to a single Machine instance, but can be configured via spec.strategy.rollingUpdate.maxSurge.
+// With a surge greater than one, an index that is waiting on its replacement does not stop a later index from being
+// started, up to that surge.
 //
 // Once a replacement Machine is ready, the strategy should also delete the old Machine to allow it to be removed from
 // the cluster.
@@ -146,81 +570,86 @@ func (r *ControlPlaneMachineSetReconciler) reconcileMachineRollingUpdate(ctx con
 	// are executed prioritizing the lower indexes first.
 	sortedIndexedMs := sortMachineInfos(indexedMachineInfos)
 
+	// Before making any create or delete call, ensure every Machine participating in this rollout is
+	// protected from racing with a MachineHealthCheck, so that concurrent remediation cannot interfere
+	// with the rollout's own create/delete sequencing.
+	if err := r.reconcileUpgradeAnnotations(ctx, logger, cpms, sortedIndexedMs, buildUpgradeTracker(sortedIndexedMs)); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// The maximum number of machines that
 	// can be scheduled above the original number of desired machines.
-	// At present, the surge is limited to a single Machine instance.
-	maxSurge := 1
+	// Defaults to a single Machine instance, but the user may configure a larger surge via
+	// spec.strategy.rollingUpdate.maxSurge, as an absolute number or a percentage of spec.replicas.
+	maxSurge := effectiveMaxSurge(cpms)
 	// Devise the existing surge and keep track of the current surge count.
 	// No check for early stoppage is done here,
 	// as deletions can continue even if the maxSurge has been already reached.
 	surgeCount := deviseExistingSurge(cpms, sortedIndexedMs)
 
-	// Reconcile any index with no Machine first.
+	// Reconcile every index in order, taking the first action (create or delete) that is available.
+	// An index that is merely waiting on a previous step (a pending replacement becoming Ready, or an
+	// outdated Machine being removed) does not block later indexes from being considered in the same
+	// pass: this is what allows maxSurge greater than one to actually surge more than one index at a
+	// time, rather than serializing the whole rollout behind whichever index happens to sort first.
 	for idx, machines := range sortedIndexedMs {
 		if empty(machines) {
 			// There are No Machines for this index.
 			// Create a new Machine for it.
-			logger = logger.WithValues("index", idx, "namespace", r.Namespace, "name", "<Unknown>")
-			return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount)
+			logger := logger.WithValues("index", idx, "namespace", r.Namespace, "name", "<Unknown>")
+			return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount, r.SSACache)
 		}
-	}
 
-	// Reconcile any index with no Ready Machines but a replacement pending.
-	for idx, machines := range sortedIndexedMs {
 		// Find out if and what Machines in this index need an update.
 		machinesPending := pendingMachines(machines)
 		if empty(readyMachines(machines)) && hasAny(machinesPending) {
 			// There are No Ready Machines for this index but a Pending Machine Replacement is present.
-			// Wait for it to become Ready.
+			// Wait for it to become Ready, and let other indexes make progress in the meantime.
 			// Consider the first found pending machine for this index to be the replacement machine.
 			replacementMachine := machinesPending[0]
-			logger = logger.WithValues("index", idx, "namespace", r.Namespace, "name", replacementMachine.MachineRef.ObjectMeta.Name)
-			logger.V(2).Info(waitingForReady)
-			return ctrl.Result{}, nil
+			logger.WithValues("index", idx, "namespace", r.Namespace, "name", replacementMachine.MachineRef.ObjectMeta.Name).V(2).Info(waitingForReady)
+			continue
 		}
-	}
 
-	// Reconcile machines that need an update.
-	for idx, machines := range sortedIndexedMs {
-		// Find out if and what Machines in this index need an update.
 		outdatedMs := needUpdateMachines(machines)
-		if hasAny(outdatedMs) {
-			// Some Machines need an update for this index.
-			// For this reconciliation, just consider the first Machine that needs update for this index.
-			outdatedMachine := outdatedMs[0]
-			logger = logger.WithValues("index", idx, "namespace", r.Namespace, "name", outdatedMachine.MachineRef.ObjectMeta.Name)
-
-			// Check if an Updated (Spec up-to-date and Ready) Machine replacement already exists for this index.
-			if hasAny(updatedMachines(machines)) {
-				// A replacement exists.
-				if !isDeletedMachine(outdatedMachine) {
-					// The Outdated Machine is still around.
-					// Now that an Updated replacement exists for it,
-					// it's safe to trigger its Deletion.
-					return deleteMachine(ctx, logger, machineProvider, outdatedMachine, r.Namespace, idx)
-				}
+		if !hasAny(outdatedMs) {
+			continue
+		}
 
-				// The Outdated Machine has already been marked for deletion.
-				// Wait for its removal.
-				logger.V(2).Info(waitingForRemoved)
-				return ctrl.Result{}, nil
-			}
+		// Some Machines need an update for this index.
+		// For this reconciliation, just consider the first Machine that needs update for this index.
+		outdatedMachine := outdatedMs[0]
+		logger := logger.WithValues("index", idx, "namespace", r.Namespace, "name", outdatedMachine.MachineRef.ObjectMeta.Name)
 
-			// Check if a Pending (Spec up-to-date but Non Ready) Replacement is present for the index.
-			machinesPending := pendingMachines(machines)
-			if hasAny(machinesPending) {
-				// A Pending Machine Replacement is present.
-				// Wait for it to become Ready.
-				// Consider the first found pending machine for this index to be the replacement machine.
-				replacementMachine := machinesPending[0]
-				logger.V(2).WithValues("replacementName", replacementMachine.MachineRef.ObjectMeta.Name).Info(waitingForReplacement)
-				return ctrl.Result{}, nil
+		// Check if an Updated (Spec up-to-date and Ready) Machine replacement already exists for this index.
+		if hasAny(updatedMachines(machines)) {
+			// A replacement exists.
+			if !isDeletedMachine(outdatedMachine) {
+				// The Outdated Machine is still around.
+				// Now that an Updated replacement exists for it,
+				// it's safe to trigger its Deletion.
+				return deleteMachine(ctx, logger, machineProvider, outdatedMachine, r.Namespace, idx, r.SSACache)
 			}
 
-			// No Healthy or Pending Replacement Machine exists,
-			// trigger a Machine creation.
-			return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount)
+			// The Outdated Machine has already been marked for deletion.
+			// Wait for its removal, and let other indexes make progress in the meantime.
+			logger.V(2).Info(waitingForRemoved)
+			continue
+		}
+
+		// Check if a Pending (Spec up-to-date but Non Ready) Replacement is present for the index.
+		if hasAny(machinesPending) {
+			// A Pending Machine Replacement is present.
+			// Wait for it to become Ready, and let other indexes make progress in the meantime.
+			// Consider the first found pending machine for this index to be the replacement machine.
+			replacementMachine := machinesPending[0]
+			logger.V(2).WithValues("replacementName", replacementMachine.MachineRef.ObjectMeta.Name).Info(waitingForReplacement)
+			continue
 		}
+
+		// No Healthy or Pending Replacement Machine exists,
+		// trigger a Machine creation.
+		return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount, r.SSACache)
 	}
 
 	// If here it means no updates were required.
@@ -229,19 +658,235 @@ func (r *ControlPlaneMachineSetReconciler) reconcileMachineRollingUpdate(ctx con
 	return ctrl.Result{}, nil
 }
 
-// reconcileMachineOnDeleteUpdate implements the rolling update strategy for the ControlPlaneMachineSet. It uses the
+// reconcileMachineOnDeleteUpdate implements the on-delete update strategy for the ControlPlaneMachineSet. It uses the
 // indexed machine information to determine when a new Machine is required to be created. When a new Machine is required,
 // it uses the machine provider to create the new Machine.
 //
 // For on-delete updates, a new Machine is required when a machine index has a Machine with a non-zero deletion
-// timestamp but does not yet have a replacement created.
+// timestamp but does not yet have a replacement created. Unlike the rolling update strategy, this strategy never
+// deletes an outdated Machine itself; the user must delete the Machine to trigger the creation of its replacement.
+// Machines that need an update, but have not yet been deleted by the user, are reported via a log message only.
 //
 // In certain scenarios, there may be indexes with missing Machines. In these circumstances, the update should attempt
 // to create a new Machine to fulfil the requirement of that index.
 func (r *ControlPlaneMachineSetReconciler) reconcileMachineOnDeleteUpdate(ctx context.Context, logger logr.Logger, cpms *machinev1.ControlPlaneMachineSet, machineProvider machineproviders.MachineProvider, indexedMachineInfos map[int32][]machineproviders.MachineInfo) (ctrl.Result, error) {
+	logger = logger.WithValues("updateStrategy", cpms.Spec.Strategy.Type)
+
+	// To ensure an ordered and safe reconciliation,
+	// one index at a time is considered.
+	// Indexes are sorted in ascendent order, so that all the operations of the same importance,
+	// are executed prioritizing the lower indexes first.
+	sortedIndexedMs := sortMachineInfos(indexedMachineInfos)
+
+	// Before making any create call, ensure every Machine participating in this rollout is protected
+	// from racing with a MachineHealthCheck.
+	if err := r.reconcileUpgradeAnnotations(ctx, logger, cpms, sortedIndexedMs, buildUpgradeTracker(sortedIndexedMs)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The maximum number of machines that
+	// can be scheduled above the original number of desired machines.
+	// At present, the surge is limited to a single Machine instance.
+	maxSurge := 1
+	// Devise the existing surge and keep track of the current surge count.
+	surgeCount := deviseExistingSurge(cpms, sortedIndexedMs)
+
+	// Reconcile any index with no Machine first.
+	for idx, machines := range sortedIndexedMs {
+		if empty(machines) {
+			// There are No Machines for this index.
+			// Create a new Machine for it.
+			logger = logger.WithValues("index", idx, "namespace", r.Namespace, "name", "<Unknown>")
+			return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount, r.SSACache)
+		}
+	}
+
+	// requiresUpdate tracks whether any index has an outdated Machine that the user has not yet
+	// deleted, so that noUpdatesRequired is only logged once everything is settled.
+	requiresUpdate := false
+
+	// Reconcile indexes where the user has deleted the outdated Machine to trigger the strategy.
+	for idx, machines := range sortedIndexedMs {
+		deletedMs := deletedMachines(machines)
+		if !hasAny(deletedMs) {
+			continue
+		}
+
+		logger := logger.WithValues("index", idx, "namespace", r.Namespace, "name", deletedMs[0].MachineRef.ObjectMeta.Name)
+
+		if hasAny(updatedMachines(machines)) {
+			// A Ready, up-to-date replacement already exists for this index.
+			// There is nothing further for this strategy to do; removal of the old
+			// Machine is driven entirely by the user.
+			continue
+		}
+
+		// Check if a Pending (Spec up-to-date but Non Ready) Replacement is present for the index.
+		machinesPending := pendingMachines(machines)
+		if hasAny(machinesPending) {
+			// A Pending Machine Replacement is present.
+			// Wait for it to become Ready.
+			replacementMachine := machinesPending[0]
+			logger.WithValues("replacementName", replacementMachine.MachineRef.ObjectMeta.Name).V(2).Info(waitingForReady)
+
+			return ctrl.Result{}, nil
+		}
+
+		// No Replacement Machine exists yet for this deleted Machine,
+		// trigger a Machine creation, respecting the configured surge.
+		return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount, r.SSACache)
+	}
+
+	// Reconcile indexes where the Machine needs an update but has not yet been deleted by the user.
+	for idx, machines := range sortedIndexedMs {
+		outdatedMs := needUpdateMachines(machines)
+
+		for _, outdatedMachine := range outdatedMs {
+			if isDeletedMachine(outdatedMachine) {
+				// Already handled above.
+				continue
+			}
+
+			requiresUpdate = true
+
+			logger.WithValues("index", idx, "namespace", r.Namespace, "name", outdatedMachine.MachineRef.ObjectMeta.Name).V(2).Info(machineRequiresUpdate)
+		}
+	}
+
+	if !requiresUpdate {
+		// If here it means no updates were required.
+		logger.V(4).Info(noUpdatesRequired)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deletedMachines returns the list of MachineInfo which have a Machine marked for deletion.
+func deletedMachines(machinesInfo []machineproviders.MachineInfo) []machineproviders.MachineInfo {
+	result := []machineproviders.MachineInfo{}
+
+	for i := range machinesInfo {
+		if isDeletedMachine(machinesInfo[i]) {
+			result = append(result, machinesInfo[i])
+		}
+	}
+
+	return result
+}
+
+// reconcileMachineInPlaceUpdate implements the InPlace update strategy for the ControlPlaneMachineSet. Instead of
+// replacing an outdated Machine, this strategy asks the machine provider to mutate the existing Machine's provider
+// spec directly, leaving surge and ordering concerns to the machine provider's underlying infrastructure.
+//
+// Indexes are processed sequentially: only one index may be patched in place at a time, so that a failed or
+// long-running in-place update cannot mask problems on a second index. A Machine part-way through being patched is
+// reported via the InPlaceUpdating state on its MachineInfo, and this strategy waits for it to become Ready before
+// considering any other index.
+//
+// If the machine provider reports that it cannot make the requested change in place, this strategy sets a Degraded
+// condition with reason reasonInPlaceUnsupported rather than silently falling back to replacing the Machine.
+//
+// In certain scenarios, there may be indexes with missing Machines. In these circumstances, the update should attempt
+// to create a new Machine to fulfil the requirement of that index, identical to the rolling update strategy.
+func (r *ControlPlaneMachineSetReconciler) reconcileMachineInPlaceUpdate(ctx context.Context, logger logr.Logger, cpms *machinev1.ControlPlaneMachineSet, machineProvider machineproviders.MachineProvider, indexedMachineInfos map[int32][]machineproviders.MachineInfo) (ctrl.Result, error) {
+	logger = logger.WithValues("updateStrategy", cpms.Spec.Strategy.Type)
+
+	// To ensure an ordered and safe reconciliation,
+	// one index at a time is considered.
+	// Indexes are sorted in ascendent order, so that all the operations of the same importance,
+	// are executed prioritizing the lower indexes first.
+	sortedIndexedMs := sortMachineInfos(indexedMachineInfos)
+
+	// Before patching or creating any Machine, ensure every Machine participating in this rollout is
+	// protected from racing with a MachineHealthCheck.
+	if err := r.reconcileUpgradeAnnotations(ctx, logger, cpms, sortedIndexedMs, buildUpgradeTracker(sortedIndexedMs)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	maxSurge := effectiveMaxSurge(cpms)
+	surgeCount := deviseExistingSurge(cpms, sortedIndexedMs)
+
+	// Reconcile any index with no Machine first.
+	for idx, machines := range sortedIndexedMs {
+		if empty(machines) {
+			logger = logger.WithValues("index", idx, "namespace", r.Namespace, "name", "<Unknown>")
+			return createMachine(ctx, logger, machineProvider, idx, maxSurge, &surgeCount, r.SSACache)
+		}
+	}
+
+	// Only one index may be patched in place at a time, so wait for any Machine that is already
+	// mid-update, or that is up to date but not yet Ready, before considering whether any other
+	// index needs an update.
+	for idx, machines := range sortedIndexedMs {
+		waitingMs := append(inPlaceUpdatingMachines(machines), pendingMachines(machines)...)
+		if hasAny(waitingMs) {
+			waitingMachine := waitingMs[0]
+			logger.WithValues("index", idx, "namespace", r.Namespace, "name", waitingMachine.MachineRef.ObjectMeta.Name).V(2).Info(waitingForReady)
+
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// Reconcile the first index, in order, that needs an update.
+	for idx, machines := range sortedIndexedMs {
+		outdatedMs := needUpdateMachines(machines)
+		if !hasAny(outdatedMs) {
+			continue
+		}
+
+		outdatedMachine := outdatedMs[0]
+		logger := logger.WithValues("index", idx, "namespace", r.Namespace, "name", outdatedMachine.MachineRef.ObjectMeta.Name)
+
+		if !machineProvider.SupportsInPlace(int32(idx)) {
+			// The provider cannot mutate this Machine's provider spec in place for this index's desired
+			// change. Degrade rather than silently falling back to replacing the Machine, so that the
+			// discrepancy is visible to the user.
+			meta.SetStatusCondition(&cpms.Status.Conditions, metav1.Condition{
+				Type:    conditionDegraded,
+				Status:  metav1.ConditionTrue,
+				Reason:  reasonInPlaceUnsupported,
+				Message: inPlaceUnsupportedMessage,
+			})
+
+			logger.Error(errInPlaceUnsupported, inPlaceUnsupportedMessage)
+
+			return ctrl.Result{}, nil
+		}
+
+		// The machine provider derives the desired provider spec for the Machine from its index,
+		// identical to CreateMachine and SupportsInPlace, and applies it to the existing Machine in
+		// place.
+		if err := machineProvider.PatchMachineInPlace(ctx, logger, outdatedMachine.MachineRef, int32(idx), r.SSACache); err != nil {
+			werr := fmt.Errorf("error patching Machine %s/%s in place: %w", r.Namespace, outdatedMachine.MachineRef.ObjectMeta.Name, err)
+			logger.Error(werr, errorPatchingMachine)
+
+			return ctrl.Result{}, werr
+		}
+
+		logger.V(2).Info(patchedMachineInPlace)
+
+		return ctrl.Result{}, nil
+	}
+
+	logger.V(4).Info(noUpdatesRequired)
+
 	return ctrl.Result{}, nil
 }
 
+// inPlaceUpdatingMachines returns the list of MachineInfo which have a Machine that is currently being
+// patched in place as part of the InPlace update strategy.
+func inPlaceUpdatingMachines(machinesInfo []machineproviders.MachineInfo) []machineproviders.MachineInfo {
+	result := []machineproviders.MachineInfo{}
+
+	for i := range machinesInfo {
+		if machinesInfo[i].InPlaceUpdating {
+			result = append(result, machinesInfo[i])
+		}
+	}
+
+	return result
+}
+
 // isDeletedMachine checks if a machine is deleted.
 func isDeletedMachine(m machineproviders.MachineInfo) bool {
 	return m.MachineRef.ObjectMeta.DeletionTimestamp != nil
@@ -310,6 +955,30 @@ func sortMachineInfos(indexedMachineInfos map[int32][]machineproviders.MachineIn
 	return slice
 }
 
+// effectiveMaxSurge computes the maximum number of Machines that may be surged above spec.replicas
+// for the RollingUpdate strategy. When spec.strategy.rollingUpdate.maxSurge is unset, it defaults to
+// defaultMaxSurge. When it is set, it is resolved to an absolute value, rounding percentages of
+// spec.replicas up, in line with intstr.GetScaledValueFromIntOrPercent.
+//
+// The ControlPlaneMachineSet webhook rejects a configured value that resolves to 0, so this function
+// does not guard against that case. Neither the webhook nor this function checks the resolved surge
+// against etcd quorum.
+func effectiveMaxSurge(cpms *machinev1.ControlPlaneMachineSet) int {
+	rollingUpdate := cpms.Spec.Strategy.RollingUpdate
+	if rollingUpdate == nil || rollingUpdate.MaxSurge == nil {
+		return defaultMaxSurge
+	}
+
+	maxSurge, err := intstr.GetScaledValueFromIntOrPercent(rollingUpdate.MaxSurge, int(*cpms.Spec.Replicas), true)
+	if err != nil {
+		// The webhook should have already rejected a malformed value, fall back to the default
+		// rather than failing the reconcile.
+		return defaultMaxSurge
+	}
+
+	return maxSurge
+}
+
 // deviseExistingSurge computes the current amount of replicas surge for the ControlPlaneMachineSet.
 func deviseExistingSurge(cpms *machinev1.ControlPlaneMachineSet, mis [][]machineproviders.MachineInfo) int {
 	desiredReplicas := int(*cpms.Spec.Replicas)
@@ -332,25 +1001,37 @@ func empty(machinesInfo []machineproviders.MachineInfo) bool {
 	return len(machinesInfo) == 0
 }
 
-// deleteMachine deletes the Machine provided.
-func deleteMachine(ctx context.Context, logger logr.Logger, machineProvider machineproviders.MachineProvider, outdatedMachine machineproviders.MachineInfo, namespace string, idx int) (ctrl.Result, error) {
-	if err := machineProvider.DeleteMachine(ctx, logger, outdatedMachine.MachineRef); err != nil {
-		werr := fmt.Errorf("error deleting Machine %s/%s: %w", namespace, outdatedMachine.MachineRef.ObjectMeta.Name, err)
+// machineGVK identifies the Machine objects that the SSA cache's entries are keyed against.
+var machineGVK = machinev1beta1.GroupVersion.WithKind("Machine")
+
+// deleteMachine deletes the Machine provided. The cache, when non-nil, is passed through to the machine provider
+// so that it can short-circuit a no-op Server-Side Apply request against the same object. Once the Machine is
+// deleted, any cached Apply result for it is stale, so it is evicted rather than left to expire on its own TTL.
+func deleteMachine(ctx context.Context, logger logr.Logger, machineProvider machineproviders.MachineProvider, outdatedMachine machineproviders.MachineInfo, namespace string, idx int, cache *ssa.Cache) (ctrl.Result, error) {
+	name := outdatedMachine.MachineRef.ObjectMeta.Name
+
+	if err := machineProvider.DeleteMachine(ctx, logger, outdatedMachine.MachineRef, cache); err != nil {
+		werr := fmt.Errorf("error deleting Machine %s/%s: %w", namespace, name, err)
 		logger.Error(werr, errorDeletingMachine)
 		return ctrl.Result{}, werr
 	}
 
+	if cache != nil {
+		cache.Evict(machineGVK, namespace, name)
+	}
+
 	logger.V(2).Info(removingOldMachine)
 	return ctrl.Result{}, nil
 }
 
-// createMachine creates the Machine provided.
-func createMachine(ctx context.Context, logger logr.Logger, machineProvider machineproviders.MachineProvider, idx int, maxSurge int, surgeCount *int) (ctrl.Result, error) {
+// createMachine creates the Machine provided. The cache, when non-nil, is passed through to the machine provider
+// so that it can short-circuit a no-op Server-Side Apply request against the same object.
+func createMachine(ctx context.Context, logger logr.Logger, machineProvider machineproviders.MachineProvider, idx int, maxSurge int, surgeCount *int, cache *ssa.Cache) (ctrl.Result, error) {
 	// Check if a surge in Machines is allowed.
 	if *surgeCount < maxSurge {
 		// There is still room to surge,
 		// trigger a Replacement Machine creation.
-		if err := machineProvider.CreateMachine(ctx, logger, int32(idx)); err != nil {
+		if err := machineProvider.CreateMachine(ctx, logger, int32(idx), cache); err != nil {
 			werr := fmt.Errorf("error creating new Machine for index %d: %w", idx, err)
 			logger.Error(werr, errorCreatingMachine)
 			return ctrl.Result{}, werr
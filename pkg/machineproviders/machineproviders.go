@@ -0,0 +1,98 @@
+/*
+Copyright 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package machineproviders defines the interface through which the ControlPlaneMachineSet controller
+// discovers and mutates the Machines for a particular platform, without the controller itself needing
+// to know how to construct a provider spec for any given platform.
+package machineproviders
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ObjectRef identifies a Machine that a MachineProvider has been asked to act on.
+type ObjectRef struct {
+	// ObjectMeta carries the identifying metadata of the Machine: its name, labels, annotations, and
+	// deletion state.
+	ObjectMeta metav1.ObjectMeta
+}
+
+// MachineInfo describes the state of a single Machine belonging to a particular control plane index, as
+// observed by a MachineProvider. The controller uses this information to decide what action, if any, an
+// update or remediation strategy should take for the index.
+type MachineInfo struct {
+	// MachineRef identifies the Machine that this MachineInfo describes.
+	MachineRef *ObjectRef
+
+	// Ready indicates that the Machine has an associated Node that is healthy and ready to serve the
+	// control plane.
+	Ready bool
+
+	// NeedsUpdate indicates that the Machine's provider spec no longer matches the ControlPlaneMachineSet's
+	// template, and so the Machine must be replaced or patched in place to bring it up to date.
+	NeedsUpdate bool
+
+	// InPlaceUpdating indicates that a patch applying an in-place update to this Machine has already been
+	// sent and has not yet completed. Only meaningful when the InPlace update strategy is in use.
+	InPlaceUpdating bool
+
+	// Unhealthy indicates that the Machine has failed a health check and is a candidate for remediation.
+	Unhealthy bool
+
+	// UnhealthyReason is a short, machine-readable reason describing why Unhealthy is set, suitable for
+	// inclusion in logs and status conditions.
+	UnhealthyReason string
+
+	// UnhealthySince records when Unhealthy was first observed to be true. It is nil whenever Unhealthy is
+	// false. Remediation uses it to require a Machine to have been continuously unhealthy for at least
+	// spec.remediation.minHealthyPeriod before it is remediated, so that a Machine which recovers on its own
+	// shortly after going unhealthy is not needlessly replaced.
+	UnhealthySince *metav1.Time
+}
+
+// MachineProvider knows how to discover, create, delete, and in some cases patch in place, the Machines
+// backing a single index of a ControlPlaneMachineSet. Implementations are specific to a cloud platform,
+// translating a ControlPlaneMachineSet's template into whatever provider spec that platform's Machine API
+// requires.
+//
+// The cache parameter threaded through Create/Delete/PatchMachineInPlace is the reconciler's
+// *ssa.Cache. It is accepted as interface{} so that this package does not need to import
+// pkg/util/ssa, keeping the platform-specific implementations free to treat it as an opaque token to pass
+// back to the shared Server-Side Apply helpers they are built on. A nil cache is always valid and simply
+// disables the short-circuit.
+type MachineProvider interface {
+	// MachineInfos returns the current MachineInfo for every Machine backing the ControlPlaneMachineSet,
+	// indexed by control plane index.
+	MachineInfos(ctx context.Context, logger logr.Logger) (map[int32][]MachineInfo, error)
+
+	// CreateMachine creates a new Machine for the given index, using the ControlPlaneMachineSet's template
+	// to construct its provider spec.
+	CreateMachine(ctx context.Context, logger logr.Logger, index int32, cache interface{}) error
+
+	// DeleteMachine deletes the Machine identified by machineRef.
+	DeleteMachine(ctx context.Context, logger logr.Logger, machineRef *ObjectRef, cache interface{}) error
+
+	// SupportsInPlace reports whether the provider can mutate the Machine identified by index's provider
+	// spec in place to bring it up to date, rather than requiring the Machine to be replaced.
+	SupportsInPlace(index int32) bool
+
+	// PatchMachineInPlace mutates the provider spec of the Machine identified by machineRef, for the given
+	// index, in place. Callers must first confirm SupportsInPlace(index) returns true.
+	PatchMachineInPlace(ctx context.Context, logger logr.Logger, machineRef *ObjectRef, index int32, cache interface{}) error
+}
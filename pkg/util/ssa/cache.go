@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ssa provides a cache of the last successfully applied Server-Side Apply request for a given object, so
+// that a subsequent Apply call for the same desired state can be short-circuited without a round trip to the API
+// server. This mirrors the request-caching pattern used by the Cluster API KubeadmControlPlane controller.
+package ssa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// defaultTTL is the length of time a cache entry is considered valid for. An entry older than this is treated as a
+// miss even if it has not been explicitly evicted, so that a Cache does not mask a change made to an object outside
+// of the controller's view indefinitely.
+const defaultTTL = 10 * time.Minute
+
+// Key identifies a single Server-Side Apply request: the object being applied to, the resource version it was
+// observed at, and a hash of the desired spec being applied. Two Apply calls that produce the same Key are known to
+// be equivalent.
+type Key struct {
+	GVK             schema.GroupVersionKind
+	Namespace       string
+	Name            string
+	ResourceVersion string
+	SpecHash        string
+}
+
+// entry records when a given Key was last successfully applied.
+type entry struct {
+	appliedAt time.Time
+}
+
+// Cache records the last successfully applied Server-Side Apply request for each object it is asked about. A
+// second Apply request that produces an identical Key within the TTL is known to be a no-op and can be skipped.
+// An entry is dropped once it is older than the TTL, or when Evict is called for its object. The Cache does not
+// observe watch events itself: an entry is removed early only where a caller calls Evict.
+type Cache struct {
+	entries sync.Map // map[Key]entry
+	ttl     time.Duration
+}
+
+// NewCache creates a Cache using the default TTL.
+func NewCache() *Cache {
+	return &Cache{ttl: defaultTTL}
+}
+
+// ShouldApply reports whether an Apply request for key needs to be sent to the API server. It returns false only
+// when the same key was recorded via RecordApplied within the TTL, meaning the request is known to be a no-op.
+func (c *Cache) ShouldApply(key Key) bool {
+	value, ok := c.entries.Load(key)
+	if !ok {
+		return true
+	}
+
+	e, ok := value.(entry)
+	if !ok || time.Since(e.appliedAt) > c.ttl {
+		c.entries.Delete(key)
+		return true
+	}
+
+	return false
+}
+
+// RecordApplied records that the Apply request for key has just succeeded.
+func (c *Cache) RecordApplied(key Key) {
+	c.entries.Store(key, entry{appliedAt: time.Now()})
+}
+
+// Evict removes every cached entry for the named object, regardless of the resource version or spec hash it was
+// cached under. Callers should invoke this on a watch event for the object, since any change observed outside of a
+// cached Apply call invalidates the cached result.
+func (c *Cache) Evict(gvk schema.GroupVersionKind, namespace, name string) {
+	c.entries.Range(func(k, _ interface{}) bool {
+		key, ok := k.(Key)
+		if ok && key.GVK == gvk && key.Namespace == namespace && key.Name == name {
+			c.entries.Delete(key)
+		}
+
+		return true
+	})
+}
+
+// HashSpec computes a stable hash of the desired spec of an Apply request, for use as the SpecHash field of a Key.
+func HashSpec(spec interface{}) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling desired spec: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssa
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func testKey() Key {
+	return Key{
+		GVK:             schema.GroupVersionKind{Group: "machine.openshift.io", Version: "v1beta1", Kind: "Machine"},
+		Namespace:       "openshift-machine-api",
+		Name:            "master-0",
+		ResourceVersion: "1",
+		SpecHash:        "abc123",
+	}
+}
+
+// TestCacheShouldApplyShortCircuits covers the acceptance scenario: a second identical Apply request, recorded via
+// RecordApplied, is known to be a no-op and should not be re-sent.
+func TestCacheShouldApplyShortCircuits(t *testing.T) {
+	c := NewCache()
+	key := testKey()
+
+	if !c.ShouldApply(key) {
+		t.Fatalf("expected a never-seen key to require an Apply")
+	}
+
+	c.RecordApplied(key)
+
+	if c.ShouldApply(key) {
+		t.Fatalf("expected an identical key recorded within the TTL to short-circuit the Apply")
+	}
+}
+
+func TestCacheShouldApplyDiffersOnSpecHash(t *testing.T) {
+	c := NewCache()
+	key := testKey()
+	c.RecordApplied(key)
+
+	changed := key
+	changed.SpecHash = "different"
+
+	if !c.ShouldApply(changed) {
+		t.Fatalf("expected a changed SpecHash to require a new Apply")
+	}
+}
+
+func TestCacheEvict(t *testing.T) {
+	c := NewCache()
+	key := testKey()
+	c.RecordApplied(key)
+
+	c.Evict(key.GVK, key.Namespace, key.Name)
+
+	if !c.ShouldApply(key) {
+		t.Fatalf("expected an evicted key to require a new Apply")
+	}
+}
+
+func TestHashSpecStableAndDistinct(t *testing.T) {
+	a, err := HashSpec(map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := HashSpec(map[string]string{"foo": "bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a != b {
+		t.Fatalf("expected identical specs to hash identically, got %q and %q", a, b)
+	}
+
+	c, err := HashSpec(map[string]string{"foo": "baz"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a == c {
+		t.Fatalf("expected different specs to hash differently")
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks contains the admission webhooks that validate the ControlPlaneMachineSet resource
+// before it reaches the controller.
+package webhooks
+
+import (
+	"context"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// ControlPlaneMachineSetWebhook validates ControlPlaneMachineSet resources. It rejects configuration that
+// the controller would otherwise have to silently fall back from: a MaxSurge or MaxInFlight percentage
+// that resolves to 0, or a MaxInFlight large enough to let remediation breach etcd quorum on its own.
+type ControlPlaneMachineSetWebhook struct{}
+
+var _ webhook.CustomValidator = &ControlPlaneMachineSetWebhook{}
+
+// SetupWebhookWithManager registers the webhook with the Manager.
+func (w *ControlPlaneMachineSetWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&machinev1.ControlPlaneMachineSet{}).
+		WithValidator(w).
+		Complete()
+}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (w *ControlPlaneMachineSetWebhook) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (w *ControlPlaneMachineSetWebhook) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator. Deletion requires no validation.
+func (w *ControlPlaneMachineSetWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate runs every check against the given ControlPlaneMachineSet.
+func validate(obj runtime.Object) error {
+	cpms, ok := obj.(*machinev1.ControlPlaneMachineSet)
+	if !ok {
+		return fmt.Errorf("expected a ControlPlaneMachineSet, got %T", obj)
+	}
+
+	// spec.replicas is validated as required by the CRD schema; nothing further can be checked here
+	// without it.
+	if cpms.Spec.Replicas == nil {
+		return nil
+	}
+
+	replicas := int(*cpms.Spec.Replicas)
+
+	if err := validateMaxSurge(cpms, replicas); err != nil {
+		return err
+	}
+
+	return validateMaxInFlight(cpms, replicas)
+}
+
+// validateMaxSurge rejects a spec.strategy.rollingUpdate.maxSurge that resolves to 0, since a rollout could
+// never create the surge Machine it needs to make progress.
+func validateMaxSurge(cpms *machinev1.ControlPlaneMachineSet, replicas int) error {
+	rollingUpdate := cpms.Spec.Strategy.RollingUpdate
+	if rollingUpdate == nil || rollingUpdate.MaxSurge == nil {
+		return nil
+	}
+
+	maxSurge, err := intstr.GetScaledValueFromIntOrPercent(rollingUpdate.MaxSurge, replicas, true)
+	if err != nil {
+		return fmt.Errorf("spec.strategy.rollingUpdate.maxSurge: %w", err)
+	}
+
+	if maxSurge <= 0 {
+		return fmt.Errorf("spec.strategy.rollingUpdate.maxSurge: must resolve to at least 1, got %d", maxSurge)
+	}
+
+	return nil
+}
+
+// validateMaxInFlight rejects a spec.remediation.maxInFlight that resolves to 0, since remediation could
+// never proceed, or to a value large enough that remediating that many Machines at once would breach etcd
+// quorum on its own, regardless of how many Machines are already otherwise unhealthy.
+func validateMaxInFlight(cpms *machinev1.ControlPlaneMachineSet, replicas int) error {
+	remediation := cpms.Spec.Remediation
+	if remediation == nil || remediation.MaxInFlight == nil {
+		return nil
+	}
+
+	maxInFlight, err := intstr.GetScaledValueFromIntOrPercent(remediation.MaxInFlight, replicas, true)
+	if err != nil {
+		return fmt.Errorf("spec.remediation.maxInFlight: %w", err)
+	}
+
+	if maxInFlight <= 0 {
+		return fmt.Errorf("spec.remediation.maxInFlight: must resolve to at least 1, got %d", maxInFlight)
+	}
+
+	quorum := replicas/2 + 1
+	if replicas-maxInFlight < quorum {
+		return fmt.Errorf("spec.remediation.maxInFlight: %d would allow remediation to breach etcd quorum (%d) for %d replicas", maxInFlight, quorum, replicas)
+	}
+
+	return nil
+}
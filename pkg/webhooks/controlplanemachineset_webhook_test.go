@@ -0,0 +1,139 @@
+/*
+Copyright 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"testing"
+
+	machinev1 "github.com/openshift/api/machine/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func cpmsWithReplicas(replicas int32) *machinev1.ControlPlaneMachineSet {
+	cpms := &machinev1.ControlPlaneMachineSet{}
+	cpms.Spec.Replicas = &replicas
+
+	return cpms
+}
+
+func TestValidateMaxSurge(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		maxSurge *intstr.IntOrString
+		wantErr  bool
+	}{
+		{
+			name:     "unset is valid",
+			replicas: 3,
+			maxSurge: nil,
+		},
+		{
+			name:     "absolute value is valid",
+			replicas: 3,
+			maxSurge: intOrStringPtr(intstr.FromInt(1)),
+		},
+		{
+			name:     "percentage resolving above 0 is valid",
+			replicas: 3,
+			maxSurge: intOrStringPtr(intstr.FromString("50%")),
+		},
+		{
+			name:     "percentage resolving to 0 is rejected",
+			replicas: 3,
+			maxSurge: intOrStringPtr(intstr.FromString("0%")),
+			wantErr:  true,
+		},
+		{
+			name:     "absolute 0 is rejected",
+			replicas: 3,
+			maxSurge: intOrStringPtr(intstr.FromInt(0)),
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpms := cpmsWithReplicas(test.replicas)
+			if test.maxSurge != nil {
+				cpms.Spec.Strategy.RollingUpdate = &machinev1.RollingUpdateControlPlaneMachineSetStrategy{MaxSurge: test.maxSurge}
+			}
+
+			err := validateMaxSurge(cpms, int(test.replicas))
+			if test.wantErr && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+
+			if !test.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateMaxInFlight(t *testing.T) {
+	tests := []struct {
+		name        string
+		replicas    int32
+		maxInFlight *intstr.IntOrString
+		wantErr     bool
+	}{
+		{
+			name:     "unset is valid",
+			replicas: 5,
+		},
+		{
+			name:        "a single in-flight remediation is valid",
+			replicas:    5,
+			maxInFlight: intOrStringPtr(intstr.FromInt(1)),
+		},
+		{
+			name:        "resolving to 0 is rejected",
+			replicas:    5,
+			maxInFlight: intOrStringPtr(intstr.FromString("0%")),
+			wantErr:     true,
+		},
+		{
+			name:        "large enough to breach quorum on its own is rejected",
+			replicas:    5,
+			maxInFlight: intOrStringPtr(intstr.FromInt(3)),
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpms := cpmsWithReplicas(test.replicas)
+			if test.maxInFlight != nil {
+				cpms.Spec.Remediation = &machinev1.ControlPlaneMachineSetRemediation{MaxInFlight: test.maxInFlight}
+			}
+
+			err := validateMaxInFlight(cpms, int(test.replicas))
+			if test.wantErr && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+
+			if !test.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func intOrStringPtr(v intstr.IntOrString) *intstr.IntOrString {
+	return &v
+}